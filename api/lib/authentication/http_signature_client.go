@@ -0,0 +1,44 @@
+package authentication
+
+import (
+	"crypto"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-fed/httpsig"
+
+	"github.com/spolu/settle/lib/errors"
+)
+
+// SignRequest signs `r` with the HTTP Signatures scheme (draft-cavage /
+// RFC 9421) expected by VerifyHTTPSignature, used by the mint's client
+// when it calls another mint instead of presenting a signed challenge.
+// It sets the `Date` header to the current time, covers `body` with a
+// `Digest` header and signs `requiredHTTPSigHeaders` with `privKey` under
+// `keyID`.
+func SignRequest(
+	r *http.Request,
+	keyID string,
+	privKey crypto.PrivateKey,
+	body []byte,
+) error {
+	r.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+
+	signer, err := httpsig.NewSigner(
+		[]httpsig.Algorithm{httpsig.ED25519},
+		httpsig.DigestSha256,
+		strings.Fields(requiredHTTPSigHeaders),
+		httpsig.Signature,
+		0,
+	)
+	if err != nil {
+		return errors.Trace(err) // 500
+	}
+
+	if err := signer.SignRequest(privKey, keyID, r, body); err != nil {
+		return errors.Trace(err) // 500
+	}
+
+	return nil
+}
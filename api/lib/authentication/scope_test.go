@@ -0,0 +1,76 @@
+package authentication
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScopeCoversGrantedCapability(t *testing.T) {
+	scope := &Scope{
+		Capabilities: []Capability{
+			{Method: "POST", PathPattern: "^/authorize$"},
+		},
+	}
+
+	if !scope.Covers(Capability{Method: "POST", PathPattern: "/authorize"}) {
+		t.Fatalf(
+			"Covers returned false for a capability explicitly granted " +
+				"by the scope")
+	}
+}
+
+func TestScopeDoesNotCoverUngrantedCapability(t *testing.T) {
+	scope := &Scope{
+		Capabilities: []Capability{
+			{Method: "GET", PathPattern: "^/authorize$"},
+		},
+	}
+
+	if scope.Covers(Capability{Method: "POST", PathPattern: "/authorize"}) {
+		t.Fatalf("Covers returned true for a method the scope did not grant")
+	}
+	if scope.Covers(Capability{Method: "GET", PathPattern: "/revoke"}) {
+		t.Fatalf("Covers returned true for a path the scope did not grant")
+	}
+}
+
+func TestScopeCoversNilIsBlanket(t *testing.T) {
+	var scope *Scope
+	if !scope.Covers(Capability{Method: "POST", PathPattern: "/anything"}) {
+		t.Fatalf("a nil Scope must cover everything")
+	}
+}
+
+func TestScopeCoversExpired(t *testing.T) {
+	scope := &Scope{
+		Capabilities: []Capability{
+			{Method: "GET", PathPattern: "^/authorize$"},
+		},
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}
+
+	if scope.Covers(Capability{Method: "GET", PathPattern: "/authorize"}) {
+		t.Fatalf("Covers returned true for an expired scope")
+	}
+}
+
+func TestParseOAuthScope(t *testing.T) {
+	scope, err := ParseOAuthScope("GET:^/authorize$ POST:^/revoke$")
+	if err != nil {
+		t.Fatalf("ParseOAuthScope: %s", err)
+	}
+	if !scope.Covers(Capability{Method: "GET", PathPattern: "/authorize"}) {
+		t.Fatalf("parsed scope does not cover its first capability")
+	}
+	if !scope.Covers(Capability{Method: "POST", PathPattern: "/revoke"}) {
+		t.Fatalf("parsed scope does not cover its second capability")
+	}
+
+	if scope, err := ParseOAuthScope(""); err != nil || scope != nil {
+		t.Fatalf("ParseOAuthScope(\"\") = %v, %v, want nil, nil", scope, err)
+	}
+
+	if _, err := ParseOAuthScope("not-a-capability"); err == nil {
+		t.Fatalf("ParseOAuthScope accepted a malformed entry")
+	}
+}
@@ -0,0 +1,103 @@
+package model
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+)
+
+// Offer is a standing offer to trade BasePair for QuotePair, denominated
+// as AssetAmounts so the traded assets and decimal Scale travel with the
+// offer rather than being reconstructed out-of-band from a bare Amount.
+type Offer struct {
+	Token     string      `db:"token"`
+	Created   time.Time   `db:"created"`
+	Owner     string      `db:"owner"`
+	BasePair  AssetAmount `db:"base_pair"`
+	QuotePair AssetAmount `db:"quote_pair"`
+	Status    OfStatus    `db:"status"`
+}
+
+// CreateOffer creates and stores a new Offer for `owner`, trading
+// `basePair` for `quotePair`.
+func CreateOffer(
+	ctx context.Context,
+	owner string,
+	basePair AssetAmount,
+	quotePair AssetAmount,
+) (*Offer, error) {
+	token, err := newMintToken("offer")
+	if err != nil {
+		return nil, errors.Trace(err) // 500
+	}
+
+	offer := &Offer{
+		Token:     token,
+		Created:   time.Now(),
+		Owner:     owner,
+		BasePair:  basePair,
+		QuotePair: quotePair,
+		Status:    OfStActive,
+	}
+
+	if err := offer.Save(ctx); err != nil {
+		return nil, errors.Trace(err) // 500
+	}
+	return offer, nil
+}
+
+// Save creates or updates the Offer.
+func (o *Offer) Save(
+	ctx context.Context,
+) error {
+	_, err := sqlx.NamedExec(db.Ext(ctx), `
+INSERT INTO offers
+  (token, created, owner, base_pair, quote_pair, status)
+VALUES
+  (:token, :created, :owner, :base_pair, :quote_pair, :status)
+ON CONFLICT (token) DO UPDATE SET status = :status
+`, o)
+	if err != nil {
+		return errors.Trace(err) // 500
+	}
+	return nil
+}
+
+// LoadOfferByToken attempts to load an Offer by its token. It returns
+// `nil` if it doesn't exist.
+func LoadOfferByToken(
+	ctx context.Context,
+	token string,
+) (*Offer, error) {
+	var offer Offer
+	err := sqlx.Get(db.Ext(ctx), &offer, `
+SELECT * FROM offers WHERE token = $1
+`, token)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Trace(err) // 500
+	}
+	return &offer, nil
+}
+
+// newMintToken generates a random, URL-safe token prefixed by `class`,
+// shared by mint entities denominated in AssetAmount (Offer, Transfer).
+func newMintToken(
+	class string,
+) (string, error) {
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return "", errors.Trace(err) // 500
+	}
+	return class + "_" +
+		base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw), nil
+}
@@ -0,0 +1,124 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+)
+
+// TrStatus is the type of a transfer status.
+type TrStatus string
+
+const (
+	// TrStPending is used to mark a transfer as pending settlement.
+	TrStPending TrStatus = "pending"
+	// TrStSettled is used to mark a transfer as settled.
+	TrStSettled TrStatus = "settled"
+	// TrStCanceled is used to mark a transfer as canceled.
+	TrStCanceled TrStatus = "canceled"
+)
+
+// Value implements driver.Valuer
+func (s TrStatus) Value() (value driver.Value, err error) {
+	return string(s), nil
+}
+
+// Scan implements sql.Scanner.
+func (s *TrStatus) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		*s = TrStatus(src)
+	case string:
+		*s = TrStatus(src)
+	default:
+		return errors.Newf("Incompatible type for TrStatus with value: %q", src)
+	}
+
+	return nil
+}
+
+// Transfer is a movement of `Amount` of a single asset from Owner to
+// Beneficiary across an Offer, denominated as an AssetAmount so the
+// asset and decimal Scale it settled at travel with the row.
+type Transfer struct {
+	Token       string      `db:"token"`
+	Created     time.Time   `db:"created"`
+	Owner       string      `db:"owner"`
+	Beneficiary string      `db:"beneficiary"`
+	OfferToken  string      `db:"offer_token"`
+	Amount      AssetAmount `db:"amount"`
+	Status      TrStatus    `db:"status"`
+}
+
+// CreateTransfer creates and stores a new pending Transfer of `amount`
+// from `owner` to `beneficiary` across the offer identified by
+// `offerToken`.
+func CreateTransfer(
+	ctx context.Context,
+	owner string,
+	beneficiary string,
+	offerToken string,
+	amount AssetAmount,
+) (*Transfer, error) {
+	token, err := newMintToken("transfer")
+	if err != nil {
+		return nil, errors.Trace(err) // 500
+	}
+
+	transfer := &Transfer{
+		Token:       token,
+		Created:     time.Now(),
+		Owner:       owner,
+		Beneficiary: beneficiary,
+		OfferToken:  offerToken,
+		Amount:      amount,
+		Status:      TrStPending,
+	}
+
+	if err := transfer.Save(ctx); err != nil {
+		return nil, errors.Trace(err) // 500
+	}
+	return transfer, nil
+}
+
+// Save creates or updates the Transfer.
+func (t *Transfer) Save(
+	ctx context.Context,
+) error {
+	_, err := sqlx.NamedExec(db.Ext(ctx), `
+INSERT INTO transfers
+  (token, created, owner, beneficiary, offer_token, amount, status)
+VALUES
+  (:token, :created, :owner, :beneficiary, :offer_token, :amount, :status)
+ON CONFLICT (token) DO UPDATE SET status = :status
+`, t)
+	if err != nil {
+		return errors.Trace(err) // 500
+	}
+	return nil
+}
+
+// LoadTransferByToken attempts to load a Transfer by its token. It
+// returns `nil` if it doesn't exist.
+func LoadTransferByToken(
+	ctx context.Context,
+	token string,
+) (*Transfer, error) {
+	var transfer Transfer
+	err := sqlx.Get(db.Ext(ctx), &transfer, `
+SELECT * FROM transfers WHERE token = $1
+`, token)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Trace(err) // 500
+	}
+	return &transfer, nil
+}
@@ -2,16 +2,325 @@ package model
 
 import (
 	"database/sql/driver"
+	"encoding/json"
 	"math/big"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
 
 	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
 )
 
+// SchemaVersion is the version of the offer/transfer amount column
+// format. It was bumped from the bare base-10 `Amount` string to the
+// composite `AssetAmount` text representation, which starts with
+// SchemaVersion itself (`"2:USD:2:12345"` = 123.45 USD). Scan rejects a
+// string without that leading version, such as `"USD:2:12345"`.
+const SchemaVersion = 2
+
+// MaxScale is the largest decimal Scale an AssetAmount is allowed to
+// carry. It is a package variable rather than a constant so deployments
+// storing assets with unusually fine-grained scales can raise it.
+var MaxScale uint8 = 24
+
+// AssetAmount is an amount denominated in a given asset, carrying the
+// decimal Scale it was recorded at so callers never have to track it
+// out-of-band. `Raw` holds the unscaled integer amount, so `123.45
+// USD` is represented as `{Raw: 12345, Scale: 2, AssetCode: "USD"}`. It
+// is named `Raw` rather than `Value` so it doesn't collide with the
+// `Value() (driver.Value, error)` method implementing driver.Valuer.
+type AssetAmount struct {
+	Raw       *big.Int
+	Scale     uint8
+	AssetCode string
+}
+
+// ParseHuman parses a human readable amount such as `"12.34 USD"` into an
+// AssetAmount, inferring the Scale from the number of digits after the
+// decimal point.
+func ParseHuman(
+	human string,
+) (*AssetAmount, error) {
+	parts := strings.Fields(human)
+	if len(parts) != 2 {
+		return nil, errors.Newf(
+			"Impossible to parse human amount: %q (expected \"<amount> "+
+				"<asset_code>\")", human)
+	}
+	amount, code := parts[0], parts[1]
+
+	scale := 0
+	digits := amount
+	if i := strings.IndexByte(amount, '.'); i >= 0 {
+		scale = len(amount) - i - 1
+		digits = amount[:i] + amount[i+1:]
+	}
+	if scale > int(MaxScale) {
+		return nil, errors.Newf(
+			"Impossible to parse human amount: %q exceeds max scale %d",
+			human, MaxScale)
+	}
+
+	value, success := new(big.Int).SetString(digits, 10)
+	if !success {
+		return nil, errors.Newf(
+			"Impossible to parse human amount: %q", human)
+	}
+
+	return &AssetAmount{
+		Raw:       value,
+		Scale:     uint8(scale),
+		AssetCode: code,
+	}, nil
+}
+
+// Format returns the canonical JSON representation of the AssetAmount,
+// used in `svc.Resp` responses so mint and register agree on amount
+// semantics over the wire.
+func (a AssetAmount) Format() string {
+	raw, err := json.Marshal(struct {
+		Value     string `json:"value"`
+		Scale     uint8  `json:"scale"`
+		AssetCode string `json:"asset_code"`
+	}{
+		Value:     a.Raw.String(),
+		Scale:     a.Scale,
+		AssetCode: a.AssetCode,
+	})
+	if err != nil {
+		// Value/Scale/AssetCode are a string, an integer and a string,
+		// so json.Marshal does not fail here; a nil Raw is rendered as
+		// the value "<nil>" rather than reaching this branch.
+		return "{}"
+	}
+	return string(raw)
+}
+
+// Scan implements sql.Scanner, decoding the composite text
+// representation (`"2:USD:2:12345"` = schema version 2, 123.45 USD)
+// written by Value. The leading SchemaVersion lets a future migration
+// tell a not-yet-migrated column (still holding a bare Amount string,
+// with no colons) or an older AssetAmount encoding apart from the
+// current one before parsing the rest.
+func (a *AssetAmount) Scan(src interface{}) error {
+	var raw string
+	switch src := src.(type) {
+	case []byte:
+		raw = string(src)
+	case string:
+		raw = src
+	default:
+		return errors.Newf("Incompatible type for AssetAmount with value: %q", src)
+	}
+
+	parts := strings.SplitN(raw, ":", 4)
+	if len(parts) != 4 {
+		return errors.Newf("Impossible to set AssetAmount with string: %q", raw)
+	}
+
+	version, err := strconv.Atoi(parts[0])
+	if err != nil || version != SchemaVersion {
+		return errors.Newf(
+			"Impossible to set AssetAmount with string: %q (expected "+
+				"schema version %d)", raw, SchemaVersion)
+	}
+
+	scale, err := strconv.ParseUint(parts[2], 10, 8)
+	if err != nil {
+		return errors.Newf("Impossible to set AssetAmount with string: %q", raw)
+	}
+
+	value, success := new(big.Int).SetString(parts[3], 10)
+	if !success {
+		return errors.Newf("Impossible to set AssetAmount with string: %q", raw)
+	}
+
+	a.AssetCode = parts[1]
+	a.Scale = uint8(scale)
+	a.Raw = value
+
+	return nil
+}
+
+// Value implements driver.Valuer, encoding the AssetAmount as the
+// composite text representation (`"2:USD:2:12345"` = schema version 2,
+// 123.45 USD) decoded by Scan.
+func (a AssetAmount) Value() (value driver.Value, err error) {
+	return strings.Join([]string{
+		strconv.Itoa(SchemaVersion),
+		a.AssetCode,
+		strconv.FormatUint(uint64(a.Scale), 10),
+		a.Raw.String(),
+	}, ":"), nil
+}
+
+// rescale returns a's Raw rescaled to `scale`, truncating with
+// banker's rounding (round-half-to-even) when `scale` is smaller than
+// a.Scale, and returns an error if `scale` exceeds MaxScale.
+func (a AssetAmount) rescale(
+	scale uint8,
+) (*big.Int, error) {
+	if scale > MaxScale {
+		return nil, errors.Newf(
+			"Impossible to rescale AssetAmount past max scale %d", MaxScale)
+	}
+
+	if scale == a.Scale {
+		return new(big.Int).Set(a.Raw), nil
+	}
+
+	if scale > a.Scale {
+		factor := new(big.Int).Exp(
+			big.NewInt(10), big.NewInt(int64(scale-a.Scale)), nil)
+		return new(big.Int).Mul(a.Raw, factor), nil
+	}
+
+	factor := new(big.Int).Exp(
+		big.NewInt(10), big.NewInt(int64(a.Scale-scale)), nil)
+	quotient, remainder := new(big.Int).QuoRem(
+		a.Raw, factor, new(big.Int))
+
+	half := new(big.Int).Div(factor, big.NewInt(2))
+	abs := new(big.Int).Abs(remainder)
+	switch abs.Cmp(half) {
+	case 1:
+		quotient = roundAwayFromZero(quotient, a.Raw.Sign())
+	case 0:
+		// Exactly halfway: round to even (banker's rounding).
+		if quotient.Bit(0) == 1 {
+			quotient = roundAwayFromZero(quotient, a.Raw.Sign())
+		}
+	}
+
+	return quotient, nil
+}
+
+// roundAwayFromZero adds (or subtracts, depending on `sign`) one unit to
+// `quotient`, used by rescale to round a truncated quotient up when the
+// remainder warrants it.
+func roundAwayFromZero(
+	quotient *big.Int,
+	sign int,
+) *big.Int {
+	if sign < 0 {
+		return new(big.Int).Sub(quotient, big.NewInt(1))
+	}
+	return new(big.Int).Add(quotient, big.NewInt(1))
+}
+
+// commonScale returns the larger of a's and o's Scale, erroring if the
+// two AssetAmounts are not denominated in the same asset.
+func (a AssetAmount) commonScale(
+	o AssetAmount,
+) (uint8, error) {
+	if a.AssetCode != o.AssetCode {
+		return 0, errors.Newf(
+			"Impossible to combine mismatched assets: %s and %s",
+			a.AssetCode, o.AssetCode)
+	}
+	scale := a.Scale
+	if o.Scale > scale {
+		scale = o.Scale
+	}
+	return scale, nil
+}
+
+// Add returns a + o, rescaled to the larger of the two Scales. It
+// returns an error if a and o are not denominated in the same asset or
+// if rescaling overflows MaxScale.
+func (a AssetAmount) Add(
+	o AssetAmount,
+) (*AssetAmount, error) {
+	scale, err := a.commonScale(o)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	av, err := a.rescale(scale)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	ov, err := o.rescale(scale)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &AssetAmount{
+		Raw:       new(big.Int).Add(av, ov),
+		Scale:     scale,
+		AssetCode: a.AssetCode,
+	}, nil
+}
+
+// Sub returns a - o, rescaled to the larger of the two Scales. It
+// returns an error if a and o are not denominated in the same asset or
+// if rescaling overflows MaxScale.
+func (a AssetAmount) Sub(
+	o AssetAmount,
+) (*AssetAmount, error) {
+	scale, err := a.commonScale(o)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	av, err := a.rescale(scale)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	ov, err := o.rescale(scale)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &AssetAmount{
+		Raw:       new(big.Int).Sub(av, ov),
+		Scale:     scale,
+		AssetCode: a.AssetCode,
+	}, nil
+}
+
+// Mul returns a scaled by the integer `factor`, keeping a's Scale and
+// AssetCode.
+func (a AssetAmount) Mul(
+	factor *big.Int,
+) *AssetAmount {
+	return &AssetAmount{
+		Raw:       new(big.Int).Mul(a.Raw, factor),
+		Scale:     a.Scale,
+		AssetCode: a.AssetCode,
+	}
+}
+
+// Cmp compares a and o after rescaling both to the larger of their two
+// Scales, returning -1, 0 or 1 as for big.Int.Cmp. It returns an error
+// if a and o are not denominated in the same asset.
+func (a AssetAmount) Cmp(
+	o AssetAmount,
+) (int, error) {
+	scale, err := a.commonScale(o)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	av, err := a.rescale(scale)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	ov, err := o.rescale(scale)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	return av.Cmp(ov), nil
+}
+
 // Amount extends big.Int to implement sql.Scanner and driver.Valuer.
+//
+// Deprecated: Amount tracks no asset or decimal scale, leaving every
+// caller to reconstruct it out-of-band. Use AssetAmount instead.
 type Amount big.Int
 
 // Scan implements sql.Scanner.
 func (b *Amount) Scan(src interface{}) error {
+	logging.Logf(context.Background(),
+		"Deprecated: scanning a legacy model.Amount; migrate to AssetAmount.")
+
 	switch src := src.(type) {
 	case int64:
 		(*big.Int)(b).SetInt64(src)
@@ -32,6 +341,9 @@ func (b *Amount) Scan(src interface{}) error {
 
 // Value implements driver.Valuer
 func (b Amount) Value() (value driver.Value, err error) {
+	logging.Logf(context.Background(),
+		"Deprecated: valuing a legacy model.Amount; migrate to AssetAmount.")
+
 	return (*big.Int)(&b).String(), nil
 }
 
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"math/big"
+	"testing"
+)
+
+func mustParseHuman(t *testing.T, human string) AssetAmount {
+	t.Helper()
+	a, err := ParseHuman(human)
+	if err != nil {
+		t.Fatalf("ParseHuman(%q): %s", human, err)
+	}
+	return *a
+}
+
+func TestAssetAmountValueScanRoundTrip(t *testing.T) {
+	a := mustParseHuman(t, "123.45 USD")
+
+	encoded, err := a.Value()
+	if err != nil {
+		t.Fatalf("Value: %s", err)
+	}
+
+	var decoded AssetAmount
+	if err := decoded.Scan(encoded); err != nil {
+		t.Fatalf("Scan(%v): %s", encoded, err)
+	}
+
+	if decoded.AssetCode != a.AssetCode || decoded.Scale != a.Scale ||
+		decoded.Raw.Cmp(a.Raw) != 0 {
+		t.Fatalf("Scan(Value()) round-trip mismatch: got %+v, want %+v",
+			decoded, a)
+	}
+}
+
+func TestAssetAmountScanRejectsStaleSchemaVersion(t *testing.T) {
+	var a AssetAmount
+	if err := a.Scan("1:USD:2:12345"); err == nil {
+		t.Fatalf("Scan accepted a string tagged with an old schema version")
+	}
+}
+
+func TestAssetAmountAddSub(t *testing.T) {
+	a := mustParseHuman(t, "1.5 USD")
+	b := mustParseHuman(t, "0.25 USD")
+
+	sum, err := a.Add(b)
+	if err != nil {
+		t.Fatalf("Add: %s", err)
+	}
+	if want := mustParseHuman(t, "1.75 USD"); cmp(t, *sum, want) != 0 {
+		t.Fatalf("1.5 + 0.25 = %s, want %s", sum.Format(), want.Format())
+	}
+
+	diff, err := a.Sub(b)
+	if err != nil {
+		t.Fatalf("Sub: %s", err)
+	}
+	if want := mustParseHuman(t, "1.25 USD"); cmp(t, *diff, want) != 0 {
+		t.Fatalf("1.5 - 0.25 = %s, want %s", diff.Format(), want.Format())
+	}
+}
+
+func TestAssetAmountAddMismatchedAsset(t *testing.T) {
+	usd := mustParseHuman(t, "1.00 USD")
+	eur := mustParseHuman(t, "1.00 EUR")
+
+	if _, err := usd.Add(eur); err == nil {
+		t.Fatalf("Add accepted mismatched assets")
+	}
+}
+
+func TestAssetAmountRescaleBankersRounding(t *testing.T) {
+	cases := []struct {
+		human string
+		scale uint8
+		want  string
+	}{
+		{"0.125 USD", 2, "12"},   // halfway, rounds to even (0.12)
+		{"0.135 USD", 2, "14"},   // halfway, rounds to even (0.14)
+		{"0.126 USD", 2, "13"},   // not halfway, rounds up
+		{"-0.125 USD", 2, "-12"}, // halfway, rounds to even (-0.12)
+	}
+	for _, c := range cases {
+		a := mustParseHuman(t, c.human)
+		got, err := a.rescale(c.scale)
+		if err != nil {
+			t.Fatalf("rescale(%q, %d): %s", c.human, c.scale, err)
+		}
+		if got.String() != c.want {
+			t.Fatalf("rescale(%q, %d) = %s, want %s",
+				c.human, c.scale, got.String(), c.want)
+		}
+	}
+}
+
+func TestAssetAmountCmp(t *testing.T) {
+	a := mustParseHuman(t, "1.50 USD")
+	b := mustParseHuman(t, "1.5 USD")
+	if cmp(t, a, b) != 0 {
+		t.Fatalf("1.50 USD should equal 1.5 USD regardless of scale")
+	}
+}
+
+func cmp(t *testing.T, a AssetAmount, b AssetAmount) int {
+	t.Helper()
+	c, err := a.Cmp(b)
+	if err != nil {
+		t.Fatalf("Cmp: %s", err)
+	}
+	return c
+}
+
+func TestAssetAmountMul(t *testing.T) {
+	a := mustParseHuman(t, "1.50 USD")
+	got := a.Mul(big.NewInt(3))
+	if want := mustParseHuman(t, "4.50 USD"); cmp(t, *got, want) != 0 {
+		t.Fatalf("1.50 USD * 3 = %s, want %s", got.Format(), want.Format())
+	}
+}
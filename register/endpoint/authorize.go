@@ -0,0 +1,188 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/spolu/settle/api/lib/authentication"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/register/model"
+)
+
+const (
+	// EndPtAuthorize renders and approves the IndieAuth-style consent
+	// page for a third-party client.
+	EndPtAuthorize EndPtName = "Authorize"
+)
+
+func init() {
+	registrar[EndPtAuthorize] = NewAuthorize
+}
+
+// Authorize implements the `response_type=code` authorization request of
+// the IndieAuth/OAuth2 authorization-code flow with PKCE. The logged-in
+// user (authenticated by `authentication.middleware`) first retrieves the
+// consent details with `approve` unset, then resubmits with
+// `approve=true` once they accept, at which point the one-time
+// AuthorizationCode is minted.
+type Authorize struct {
+	ClientID            string
+	RedirectURI         string
+	State               string
+	Scope               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Approve             bool
+
+	method string
+	path   string
+}
+
+// NewAuthorize constructs and initializes the endpoint.
+func NewAuthorize(
+	r *http.Request,
+) (Endpoint, error) {
+	return &Authorize{}, nil
+}
+
+// Validate validates the input parameters.
+func (e *Authorize) Validate(
+	r *http.Request,
+) error {
+	q := r.URL.Query()
+
+	e.method = r.Method
+	e.path = r.URL.Path
+
+	if q.Get("response_type") != "code" {
+		return errors.NewUserError(nil,
+			400, "invalid_response_type",
+			"The response_type you provided must be \"code\".",
+		) // 400
+	}
+
+	e.ClientID = q.Get("client_id")
+	e.RedirectURI = q.Get("redirect_uri")
+	e.State = q.Get("state")
+	e.Scope = q.Get("scope")
+	e.CodeChallenge = q.Get("code_challenge")
+	e.CodeChallengeMethod = q.Get("code_challenge_method")
+	e.Approve = q.Get("approve") == "true"
+
+	if e.ClientID == "" || e.RedirectURI == "" {
+		return errors.NewUserError(nil,
+			400, "missing_client",
+			"You must provide a client_id and a redirect_uri.",
+		) // 400
+	}
+	if e.CodeChallenge == "" {
+		return errors.NewUserError(nil,
+			400, "missing_code_challenge",
+			"You must provide a PKCE code_challenge.",
+		) // 400
+	}
+	if e.CodeChallengeMethod == "" {
+		e.CodeChallengeMethod = "S256"
+	}
+	if e.CodeChallengeMethod != "S256" && e.CodeChallengeMethod != "plain" {
+		return errors.NewUserError(nil,
+			400, "invalid_code_challenge_method",
+			"The code_challenge_method you provided must be \"S256\" or "+
+				"\"plain\".",
+		) // 400
+	}
+
+	// Reject a scope that does not parse as the "method:path_pattern"
+	// mini-language ParseOAuthScope expects, so a malformed or
+	// free-form scope (e.g. "profile email") is caught here rather than
+	// minting a token that will fail ParseOAuthScope on every
+	// subsequent request authenticated with it.
+	if _, err := authentication.ParseOAuthScope(e.Scope); err != nil {
+		return errors.Trace(err) // 400
+	}
+
+	return nil
+}
+
+// Execute executes the endpoint.
+func (e *Authorize) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	status := authentication.Get(ctx)
+	if status.Status != authentication.AutStSucceeded {
+		return nil, nil, errors.NewUserError(nil,
+			401, "authentication_required",
+			"You must be authenticated to authorize a client.",
+		)
+	}
+
+	// Approving a client mints an AuthorizationCode on the user's behalf,
+	// so it requires a capability beyond read-only consent viewing: a
+	// caller authenticated with a scope restricted to e.g. GET /authorize
+	// cannot approve clients through a stolen or delegated credential.
+	// Capability.PathPattern is the regex a capability allows; the
+	// requested Capability must carry the concrete request path (not
+	// another regex) for Scope.Covers's regexp.MatchString to ever match.
+	method := e.method
+	if e.Approve {
+		method = "POST"
+	}
+	if err := authentication.Require(ctx, authentication.Capability{
+		Method:      method,
+		PathPattern: e.path,
+	}); err != nil {
+		return nil, nil, errors.Trace(err) // 403
+	}
+
+	regCtx := db.Begin(ctx, "register")
+	defer db.LoggedRollback(regCtx)
+
+	username := strings.SplitN(status.Address, "@", 2)[0]
+	user, err := model.LoadUserByUsername(regCtx, username)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	} else if user == nil {
+		return nil, nil, errors.Trace(errors.NewUserError(err,
+			400, "user_not_found",
+			"The authenticated address is not associated with any "+
+				"existing user.",
+		))
+	}
+
+	if !e.Approve {
+		logging.Logf(regCtx,
+			"Authorization consent: client_id=%s username=%s scope=%s",
+			e.ClientID, user.Username, e.Scope)
+
+		return ptr.Int(http.StatusOK), &svc.Resp{
+			"client_id":    e.ClientID,
+			"redirect_uri": e.RedirectURI,
+			"scope":        e.Scope,
+			"state":        e.State,
+		}, nil
+	}
+
+	code, err := model.CreateAuthorizationCode(regCtx,
+		user, status.Address, e.ClientID, e.RedirectURI, e.Scope,
+		e.CodeChallenge, e.CodeChallengeMethod)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	}
+
+	db.Commit(regCtx)
+
+	logging.Logf(ctx,
+		"Authorization approved: client_id=%s username=%s scope=%s",
+		e.ClientID, user.Username, e.Scope)
+
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"redirect_uri": e.RedirectURI,
+		"code":         code.Token,
+		"state":        e.State,
+	}, nil
+}
@@ -0,0 +1,93 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/spolu/settle/api/lib/authentication"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/register/model"
+)
+
+const (
+	// EndPtRevoke invalidates a previously issued access token.
+	EndPtRevoke EndPtName = "Revoke"
+)
+
+func init() {
+	registrar[EndPtRevoke] = NewRevoke
+}
+
+// Revoke invalidates an AccessToken ahead of its expiry, so it is
+// rejected by `authentication.middleware` from then on.
+type Revoke struct {
+	Token string
+}
+
+// NewRevoke constructs and initializes the endpoint.
+func NewRevoke(
+	r *http.Request,
+) (Endpoint, error) {
+	return &Revoke{}, nil
+}
+
+// Validate validates the input parameters.
+func (e *Revoke) Validate(
+	r *http.Request,
+) error {
+	if err := r.ParseForm(); err != nil {
+		return errors.NewUserError(err,
+			400, "invalid_request",
+			"The request body could not be parsed.",
+		) // 400
+	}
+
+	e.Token = r.Form.Get("token")
+	if e.Token == "" {
+		return errors.NewUserError(nil,
+			400, "invalid_request",
+			"You must provide the token to revoke.",
+		) // 400
+	}
+
+	return nil
+}
+
+// Execute executes the endpoint. Per RFC 7009 it returns 200 whether or
+// not the token existed, so callers cannot probe for valid tokens. A
+// token that exists but does not belong to the authenticated caller is
+// treated the same as a nonexistent one, for the same reason.
+func (e *Revoke) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	status := authentication.Get(ctx)
+	if status.Status != authentication.AutStSucceeded {
+		return nil, nil, errors.NewUserError(nil,
+			401, "authentication_required",
+			"You must be authenticated to revoke an access token.",
+		)
+	}
+
+	regCtx := db.Begin(ctx, "register")
+	defer db.LoggedRollback(regCtx)
+
+	token, err := model.LoadAccessTokenByToken(regCtx, e.Token)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	}
+	if token != nil && token.Address == status.Address {
+		if err := token.Revoke(regCtx); err != nil {
+			return nil, nil, errors.Trace(err) // 500
+		}
+		logging.Logf(regCtx,
+			"Token revoked: client_id=%s", token.ClientID)
+	}
+
+	db.Commit(regCtx)
+
+	return ptr.Int(http.StatusOK), &svc.Resp{}, nil
+}
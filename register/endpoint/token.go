@@ -0,0 +1,153 @@
+package endpoint
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/register/model"
+)
+
+const (
+	// EndPtToken exchanges an authorization code and its PKCE verifier
+	// for a scoped access token.
+	EndPtToken EndPtName = "Token"
+)
+
+func init() {
+	registrar[EndPtToken] = NewToken
+}
+
+// Token implements the token exchange step of the authorization-code
+// flow: it consumes the one-time AuthorizationCode minted by Authorize,
+// checks the PKCE `code_verifier` against the code's `code_challenge`,
+// and mints a short-lived AccessToken scoped to what was approved.
+type Token struct {
+	GrantType    string
+	Code         string
+	RedirectURI  string
+	ClientID     string
+	CodeVerifier string
+}
+
+// NewToken constructs and initializes the endpoint.
+func NewToken(
+	r *http.Request,
+) (Endpoint, error) {
+	return &Token{}, nil
+}
+
+// Validate validates the input parameters.
+func (e *Token) Validate(
+	r *http.Request,
+) error {
+	if err := r.ParseForm(); err != nil {
+		return errors.NewUserError(err,
+			400, "invalid_request",
+			"The request body could not be parsed.",
+		) // 400
+	}
+
+	e.GrantType = r.Form.Get("grant_type")
+	e.Code = r.Form.Get("code")
+	e.RedirectURI = r.Form.Get("redirect_uri")
+	e.ClientID = r.Form.Get("client_id")
+	e.CodeVerifier = r.Form.Get("code_verifier")
+
+	if e.GrantType != "authorization_code" {
+		return errors.NewUserError(nil,
+			400, "unsupported_grant_type",
+			"The grant_type you provided must be \"authorization_code\".",
+		) // 400
+	}
+	if e.Code == "" || e.CodeVerifier == "" {
+		return errors.NewUserError(nil,
+			400, "invalid_request",
+			"You must provide a code and a code_verifier.",
+		) // 400
+	}
+
+	return nil
+}
+
+// Execute executes the endpoint.
+func (e *Token) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	regCtx := db.Begin(ctx, "register")
+	defer db.LoggedRollback(regCtx)
+
+	code, err := model.LoadAuthorizationCodeByToken(regCtx, e.Code)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	} else if code == nil || code.Consumed || code.Expired() {
+		return nil, nil, errors.Trace(errors.NewUserError(err,
+			400, "invalid_grant",
+			"The code you provided is invalid, expired or was already "+
+				"used.",
+		))
+	}
+	if code.ClientID != e.ClientID || code.RedirectURI != e.RedirectURI {
+		return nil, nil, errors.Trace(errors.NewUserError(nil,
+			400, "invalid_grant",
+			"The client_id/redirect_uri you provided do not match the "+
+				"ones the code was issued for.",
+		))
+	}
+	if !verifyCodeChallenge(
+		code.CodeChallenge, code.CodeChallengeMethod, e.CodeVerifier) {
+		return nil, nil, errors.Trace(errors.NewUserError(nil,
+			400, "invalid_grant",
+			"The code_verifier you provided does not match the "+
+				"code_challenge of the authorization code.",
+		))
+	}
+
+	code.Consumed = true
+	if err := code.Save(regCtx); err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	}
+
+	token, err := model.CreateAccessToken(regCtx,
+		code.UserToken, code.Address, code.ClientID, code.Scope,
+		code.CodeChallenge)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	}
+
+	db.Commit(regCtx)
+
+	logging.Logf(regCtx,
+		"Token issued: client_id=%s scope=%s expires_at=%q",
+		token.ClientID, token.Scope, token.ExpiresAt)
+
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"access_token": token.Token,
+		"token_type":   "Bearer",
+		"expires_in":   int64(model.AccessTokenLifetime.Seconds()),
+		"scope":        token.Scope,
+	}, nil
+}
+
+// verifyCodeChallenge recomputes the PKCE code_challenge from `verifier`
+// according to `method` and compares it in constant time against
+// `challenge`.
+func verifyCodeChallenge(
+	challenge string,
+	method string,
+	verifier string,
+) bool {
+	computed := verifier
+	if method == "S256" {
+		sum := sha256.Sum256([]byte(verifier))
+		computed = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(computed)) == 1
+}
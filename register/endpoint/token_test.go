@@ -0,0 +1,31 @@
+package endpoint
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+)
+
+func TestVerifyCodeChallengeS256(t *testing.T) {
+	verifier := "a-random-code-verifier"
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	if !verifyCodeChallenge(challenge, "S256", verifier) {
+		t.Fatalf("verifyCodeChallenge rejected a matching S256 verifier")
+	}
+	if verifyCodeChallenge(challenge, "S256", "wrong-verifier") {
+		t.Fatalf("verifyCodeChallenge accepted a mismatched S256 verifier")
+	}
+}
+
+func TestVerifyCodeChallengePlain(t *testing.T) {
+	verifier := "a-random-code-verifier"
+
+	if !verifyCodeChallenge(verifier, "plain", verifier) {
+		t.Fatalf("verifyCodeChallenge rejected a matching plain verifier")
+	}
+	if verifyCodeChallenge(verifier, "plain", "wrong-verifier") {
+		t.Fatalf("verifyCodeChallenge accepted a mismatched plain verifier")
+	}
+}
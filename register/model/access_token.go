@@ -0,0 +1,120 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+)
+
+// AccessTokenLifetime is the default validity duration of an access
+// token minted by EndPtToken.
+const AccessTokenLifetime = 1 * time.Hour
+
+// AccessToken is a short-lived, scoped bearer token exchanged by a
+// third-party client for an AuthorizationCode, consumable by
+// `authentication.middleware` as an alternative to the legacy challenge
+// scheme, which sets `Status.Address` from `Address`. `CodeChallenge` is
+// kept from the originating AuthorizationCode so the token can be traced
+// back to the consent it was issued under.
+type AccessToken struct {
+	Token         string    `db:"token"`
+	Created       time.Time `db:"created"`
+	UserToken     string    `db:"user_token"`
+	Address       string    `db:"address"`
+	ClientID      string    `db:"client_id"`
+	Scope         string    `db:"scope"`
+	CodeChallenge string    `db:"code_challenge"`
+	ExpiresAt     time.Time `db:"expires_at"`
+	Revoked       bool      `db:"revoked"`
+}
+
+// CreateAccessToken mints and stores a new AccessToken for the user
+// identified by `userToken`/`address`, scoped to `scope` on behalf of
+// `clientID`.
+func CreateAccessToken(
+	ctx context.Context,
+	userToken string,
+	address string,
+	clientID string,
+	scope string,
+	codeChallenge string,
+) (*AccessToken, error) {
+	token, err := newOAuthToken("access")
+	if err != nil {
+		return nil, errors.Trace(err) // 500
+	}
+
+	t := &AccessToken{
+		Token:         token,
+		Created:       time.Now(),
+		UserToken:     userToken,
+		Address:       address,
+		ClientID:      clientID,
+		Scope:         scope,
+		CodeChallenge: codeChallenge,
+		ExpiresAt:     time.Now().Add(AccessTokenLifetime),
+	}
+
+	if err := t.Save(ctx); err != nil {
+		return nil, errors.Trace(err) // 500
+	}
+	return t, nil
+}
+
+// Save creates or updates the AccessToken.
+func (t *AccessToken) Save(
+	ctx context.Context,
+) error {
+	_, err := sqlx.NamedExec(db.Ext(ctx), `
+INSERT INTO access_tokens
+  (token, created, user_token, address, client_id, scope, code_challenge,
+   expires_at, revoked)
+VALUES
+  (:token, :created, :user_token, :address, :client_id, :scope,
+   :code_challenge, :expires_at, :revoked)
+ON CONFLICT (token) DO UPDATE SET revoked = :revoked
+`, t)
+	if err != nil {
+		return errors.Trace(err) // 500
+	}
+	return nil
+}
+
+// LoadAccessTokenByToken attempts to load an AccessToken by its token. It
+// returns `nil` if it doesn't exist.
+func LoadAccessTokenByToken(
+	ctx context.Context,
+	token string,
+) (*AccessToken, error) {
+	var t AccessToken
+	err := sqlx.Get(db.Ext(ctx), &t, `
+SELECT * FROM access_tokens WHERE token = $1
+`, token)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Trace(err) // 500
+	}
+	return &t, nil
+}
+
+// Valid returns whether the access token has not been revoked and is not
+// past its ExpiresAt.
+func (t *AccessToken) Valid() bool {
+	return !t.Revoked && time.Now().Before(t.ExpiresAt)
+}
+
+// Revoke marks the access token as revoked, so it is rejected by
+// `authentication.middleware` from then on.
+func (t *AccessToken) Revoke(
+	ctx context.Context,
+) error {
+	t.Revoked = true
+	return errors.Trace(t.Save(ctx)) // 500
+}
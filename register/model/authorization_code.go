@@ -0,0 +1,133 @@
+package model
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+)
+
+// AuthzCodeLifetime is how long an authorization code issued by
+// EndPtAuthorize remains exchangeable for an access token.
+const AuthzCodeLifetime = 10 * time.Minute
+
+// AuthorizationCode is a one-time code, modeled on IndieAuth's
+// authorization-code flow, that a third-party client exchanges for an
+// AccessToken once the user has approved it on the consent page. It
+// carries the PKCE `code_challenge` the client attached to the original
+// /authorize request so EndPtToken can verify the matching
+// `code_verifier` before minting a token.
+type AuthorizationCode struct {
+	Token               string    `db:"token"`
+	Created             time.Time `db:"created"`
+	UserToken           string    `db:"user_token"`
+	Address             string    `db:"address"`
+	ClientID            string    `db:"client_id"`
+	RedirectURI         string    `db:"redirect_uri"`
+	Scope               string    `db:"scope"`
+	CodeChallenge       string    `db:"code_challenge"`
+	CodeChallengeMethod string    `db:"code_challenge_method"`
+	ExpiresAt           time.Time `db:"expires_at"`
+	Consumed            bool      `db:"consumed"`
+}
+
+// CreateAuthorizationCode creates and stores a new AuthorizationCode for
+// `user`/`address`, approved for `clientID`/`redirectURI`/`scope` and
+// bound to the PKCE `codeChallenge`.
+func CreateAuthorizationCode(
+	ctx context.Context,
+	user *User,
+	address string,
+	clientID string,
+	redirectURI string,
+	scope string,
+	codeChallenge string,
+	codeChallengeMethod string,
+) (*AuthorizationCode, error) {
+	token, err := newOAuthToken("authzcode")
+	if err != nil {
+		return nil, errors.Trace(err) // 500
+	}
+
+	code := &AuthorizationCode{
+		Token:               token,
+		Created:             time.Now(),
+		UserToken:           user.Token,
+		Address:             address,
+		ClientID:            clientID,
+		RedirectURI:         redirectURI,
+		Scope:               scope,
+		CodeChallenge:       codeChallenge,
+		CodeChallengeMethod: codeChallengeMethod,
+		ExpiresAt:           time.Now().Add(AuthzCodeLifetime),
+	}
+
+	if err := code.Save(ctx); err != nil {
+		return nil, errors.Trace(err) // 500
+	}
+	return code, nil
+}
+
+// Save creates or updates the AuthorizationCode.
+func (c *AuthorizationCode) Save(
+	ctx context.Context,
+) error {
+	_, err := sqlx.NamedExec(db.Ext(ctx), `
+INSERT INTO authorization_codes
+  (token, created, user_token, address, client_id, redirect_uri, scope,
+   code_challenge, code_challenge_method, expires_at, consumed)
+VALUES
+  (:token, :created, :user_token, :address, :client_id, :redirect_uri,
+   :scope, :code_challenge, :code_challenge_method, :expires_at, :consumed)
+ON CONFLICT (token) DO UPDATE SET consumed = :consumed
+`, c)
+	if err != nil {
+		return errors.Trace(err) // 500
+	}
+	return nil
+}
+
+// LoadAuthorizationCodeByToken attempts to load an AuthorizationCode by
+// its token. It returns `nil` if it doesn't exist.
+func LoadAuthorizationCodeByToken(
+	ctx context.Context,
+	token string,
+) (*AuthorizationCode, error) {
+	var code AuthorizationCode
+	err := sqlx.Get(db.Ext(ctx), &code, `
+SELECT * FROM authorization_codes WHERE token = $1
+`, token)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Trace(err) // 500
+	}
+	return &code, nil
+}
+
+// Expired returns whether the authorization code is past its
+// AuthzCodeLifetime and can no longer be exchanged.
+func (c *AuthorizationCode) Expired() bool {
+	return time.Now().After(c.ExpiresAt)
+}
+
+// newOAuthToken generates a random, URL-safe token prefixed by `class`,
+// matching the convention used by the authorization code and access
+// token entities (e.g. `authzcode_...`, `access_...`).
+func newOAuthToken(
+	class string,
+) (string, error) {
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return "", errors.Trace(err) // 500
+	}
+	return class + "_" +
+		base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw), nil
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthorizationCodeExpired(t *testing.T) {
+	code := &AuthorizationCode{ExpiresAt: time.Now().Add(-time.Second)}
+	if !code.Expired() {
+		t.Fatalf("Expired() returned false for a past ExpiresAt")
+	}
+
+	code = &AuthorizationCode{ExpiresAt: time.Now().Add(AuthzCodeLifetime)}
+	if code.Expired() {
+		t.Fatalf("Expired() returned true for a future ExpiresAt")
+	}
+}
+
+func TestNewOAuthToken(t *testing.T) {
+	token, err := newOAuthToken("authzcode")
+	if err != nil {
+		t.Fatalf("newOAuthToken: %s", err)
+	}
+	if !strings.HasPrefix(token, "authzcode_") {
+		t.Fatalf("newOAuthToken did not prefix the token with its class: %q", token)
+	}
+
+	other, err := newOAuthToken("authzcode")
+	if err != nil {
+		t.Fatalf("newOAuthToken: %s", err)
+	}
+	if token == other {
+		t.Fatalf("newOAuthToken returned the same token twice")
+	}
+}